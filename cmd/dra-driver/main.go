@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 
 	"github.com/example/dra-poc/pkg/driver"
@@ -21,6 +22,9 @@ var (
 	pluginSocket       string
 	registrationSocket string
 	resourceDir        string
+	cdiDir             string
+	adminSocket        string
+	blockClaimUIDs     string
 )
 
 func main() {
@@ -35,6 +39,9 @@ func main() {
 	cmd.Flags().StringVar(&pluginSocket, "plugin-socket", "/var/lib/kubelet/plugins/file.dra.example.com/plugin.sock", "Path to the plugin socket")
 	cmd.Flags().StringVar(&registrationSocket, "registration-socket", "", "Path to the registration socket (defaults to kubelet plugin registration dir)")
 	cmd.Flags().StringVar(&resourceDir, "resource-dir", "/etc/dra", "Directory for resource files")
+	cmd.Flags().StringVar(&cdiDir, "cdi-dir", "/var/run/cdi", "Directory for generated CDI specs")
+	cmd.Flags().StringVar(&adminSocket, "admin-socket", "", "Path to an admin socket for e2e fault injection (disabled if empty)")
+	cmd.Flags().StringVar(&blockClaimUIDs, "block-claim-uids", "", "Comma-separated <claimUID>=<mode> pairs to fault-inject at startup (modes: timeout, error, panic, empty)")
 
 	if err := cmd.Execute(); err != nil {
 		klog.Fatal(err)
@@ -64,18 +71,64 @@ func run(cmd *cobra.Command, args []string) {
 		klog.Fatalf("Failed to create resource directory: %v", err)
 	}
 
-	// Create and start the driver
-	d, err := driver.New(driverName, nodeName, pluginSocket, resourceDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// ClaimClient lets the driver decode per-claim ClaimParameters from the
+	// allocation result instead of guessing at pod identity; it's optional
+	// so the driver keeps working in environments without API server access.
+	var claimClient kubernetes.Interface
+	if config, err := rest.InClusterConfig(); err != nil {
+		klog.Warningf("Failed to get in-cluster config (will prepare claims without parameters): %v", err)
+	} else if cs, err := kubernetes.NewForConfig(config); err != nil {
+		klog.Warningf("Failed to create clientset (will prepare claims without parameters): %v", err)
+	} else {
+		claimClient = cs
+	}
+
+	initialBlocked, err := driver.ParseBlockClaimUIDs(blockClaimUIDs)
 	if err != nil {
-		klog.Fatalf("Failed to create driver: %v", err)
+		klog.Fatalf("Invalid --block-claim-uids: %v", err)
 	}
 
-	// Create the registration server
-	// The kubelet expects version strings in the format "v1.DRAPlugin" or "v1beta1.DRAPlugin"
+	cfg := driver.PluginConfig{
+		PluginSocket:         pluginSocket,
+		ResourceDir:          resourceDir,
+		FileOps:              driver.DefaultFileOperations(),
+		InitialBlockedClaims: initialBlocked,
+	}
+	if claimClient != nil {
+		cfg.ClaimClient = claimClient.ResourceV1()
+	}
+
+	// Start the DRA driver as a plugin. A binary hosting more than one
+	// driver would call driver.StartPlugin again here with a different
+	// PluginConfig and keep the returned Handle alongside this one.
+	handle, err := driver.StartPlugin(ctx, cdiDir, driverName, nodeName, cfg)
+	if err != nil {
+		klog.Fatalf("Failed to start plugin: %v", err)
+	}
+	defer handle.Stop()
+
+	// Start the admin fault-injection endpoint, used by e2e tests to drive
+	// kubelet's handling of plugin failures.
+	if adminSocket != "" {
+		admin := driver.NewAdminServer(handle.Driver())
+		go func() {
+			if err := admin.Start(ctx, adminSocket); err != nil {
+				klog.Errorf("Admin server failed: %v", err)
+			}
+		}()
+	}
+
+	// Create the registration server. GetInfo advertises every protocol
+	// version the driver's gRPC server answers (see
+	// driver.registerCompatServers), so kubelets from 1.27 through current
+	// can all use the same driver binary.
 	regServer := plugin.NewRegistrationServer(
 		driverName,
 		pluginSocket,
-		[]string{"v1.DRAPlugin"},
+		driver.SupportedVersions,
 	)
 
 	// Create the resource publisher (to publish ResourceSlices)
@@ -84,9 +137,6 @@ func run(cmd *cobra.Command, args []string) {
 		klog.Warningf("Failed to create resource publisher (will continue without it): %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	// Handle shutdown signals
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -109,10 +159,6 @@ func run(cmd *cobra.Command, args []string) {
 		}
 	}()
 
-	// Start the DRA driver (blocking)
-	if err := d.Start(ctx); err != nil {
-		klog.Fatalf("Driver failed: %v", err)
-	}
-
-	fmt.Println("Driver stopped")
+	<-ctx.Done()
+	klog.Info("Driver stopped")
 }
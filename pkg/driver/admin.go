@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+)
+
+// AdminServer exposes SetBlockedClaim over a second unix socket, so an e2e
+// test can drive NodePrepareResources/NodeUnprepareResources failure paths
+// in a running driver without restarting it or patching Driver directly.
+//
+// This mirrors the error-injection admin endpoint added to the k/k example
+// driver, as plain JSON-over-HTTP rather than a second gRPC service, since
+// this tree has no protoc-generated stubs for one.
+type AdminServer struct {
+	driver *Driver
+	server *http.Server
+}
+
+// NewAdminServer builds an admin endpoint for driver.
+func NewAdminServer(driver *Driver) *AdminServer {
+	mux := http.NewServeMux()
+	a := &AdminServer{driver: driver}
+	mux.HandleFunc("/blockClaim", a.handleBlockClaim)
+	a.server = &http.Server{Handler: mux}
+	return a
+}
+
+type blockClaimRequest struct {
+	ClaimUID string `json:"claimUID"`
+	Mode     string `json:"mode"`
+}
+
+func (a *AdminServer) handleBlockClaim(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req blockClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.ClaimUID == "" {
+		http.Error(w, "claimUID is required", http.StatusBadRequest)
+		return
+	}
+
+	mode, err := ParseBlockMode(req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.driver.SetBlockedClaim(req.ClaimUID, mode)
+	klog.Infof("Admin endpoint: claim %s now blocked with mode %s", req.ClaimUID, mode)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Start listens on socketPath and serves until ctx is done.
+func (a *AdminServer) Start(ctx context.Context, socketPath string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %w", err)
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove existing admin socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+
+	klog.Infof("Admin fault-injection endpoint listening on %s", socketPath)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := a.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return a.server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		return fmt.Errorf("admin server failed: %w", err)
+	}
+}
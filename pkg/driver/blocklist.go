@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// BlockMode is a fault to inject into NodePrepareResources/NodeUnprepareResources
+// for a specific claim, so e2e tests can exercise kubelet's handling of a
+// misbehaving DRA plugin without actually breaking the driver.
+type BlockMode int
+
+const (
+	// BlockModeNone means the claim is handled normally.
+	BlockModeNone BlockMode = iota
+	// BlockModeTimeout blocks until the caller's context is done, to
+	// simulate a plugin call that hangs past kubelet's client timeout.
+	BlockModeTimeout
+	// BlockModeReturnError returns a per-claim error in the response.
+	BlockModeReturnError
+	// BlockModePanic panics inside the handler.
+	BlockModePanic
+	// BlockModeReturnEmpty silently omits the claim from the response map,
+	// simulating a partial response for a subset of claims.
+	BlockModeReturnEmpty
+)
+
+func (m BlockMode) String() string {
+	switch m {
+	case BlockModeTimeout:
+		return "timeout"
+	case BlockModeReturnError:
+		return "error"
+	case BlockModePanic:
+		return "panic"
+	case BlockModeReturnEmpty:
+		return "empty"
+	default:
+		return "none"
+	}
+}
+
+// ParseBlockMode parses the modes accepted by --block-claim-uids and the
+// admin endpoint: "timeout", "error", "panic", "empty", or "none".
+func ParseBlockMode(s string) (BlockMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "timeout":
+		return BlockModeTimeout, nil
+	case "error":
+		return BlockModeReturnError, nil
+	case "panic":
+		return BlockModePanic, nil
+	case "empty":
+		return BlockModeReturnEmpty, nil
+	case "none", "":
+		return BlockModeNone, nil
+	default:
+		return BlockModeNone, fmt.Errorf("unknown block mode %q", s)
+	}
+}
+
+// ParseBlockClaimUIDs parses the --block-claim-uids flag value, a comma
+// separated list of "<claimUID>=<mode>" pairs, e.g.
+// "uid-1=timeout,uid-2=panic".
+func ParseBlockClaimUIDs(s string) (map[string]BlockMode, error) {
+	out := make(map[string]BlockMode)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return out, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid --block-claim-uids entry %q, want <claimUID>=<mode>", pair)
+		}
+		mode, err := ParseBlockMode(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --block-claim-uids entry %q: %w", pair, err)
+		}
+		out[parts[0]] = mode
+	}
+	return out, nil
+}
+
+// blockList is a concurrency-safe map of claimUID to the fault that should
+// be injected for it.
+type blockList struct {
+	mu    sync.RWMutex
+	modes map[string]BlockMode
+}
+
+func newBlockList() *blockList {
+	return &blockList{modes: make(map[string]BlockMode)}
+}
+
+// set records mode for claimUID, or clears it when mode is BlockModeNone.
+func (b *blockList) set(claimUID string, mode BlockMode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if mode == BlockModeNone {
+		delete(b.modes, claimUID)
+		return
+	}
+	b.modes[claimUID] = mode
+}
+
+func (b *blockList) get(claimUID string) BlockMode {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.modes[claimUID]
+}
+
+// SetBlockedClaim configures (or clears, with BlockModeNone) the fault
+// NodePrepareResources/NodeUnprepareResources should inject for claimUID.
+// It's the hook e2e tests and the admin endpoint use to drive failure paths
+// in kubelet's handling of this plugin.
+func (d *Driver) SetBlockedClaim(claimUID string, mode BlockMode) {
+	d.blocked.set(claimUID, mode)
+}
+
+// faultOutcome is what a handler should do after injectFault runs for a claim.
+type faultOutcome int
+
+const (
+	// faultNone means the claim should be handled normally.
+	faultNone faultOutcome = iota
+	// faultError means the handler should put an error in the claim's
+	// response entry and move on.
+	faultError
+	// faultEmpty means the handler should skip the claim entirely,
+	// leaving it out of the response map.
+	faultEmpty
+)
+
+// injectFault checks claimUID against the block list and applies whatever
+// fault is configured for it. BlockModeTimeout blocks until ctx is done
+// before returning faultError, simulating a plugin call that hangs past
+// kubelet's client timeout. BlockModePanic panics immediately.
+func (d *Driver) injectFault(ctx context.Context, claimUID string) faultOutcome {
+	switch d.blocked.get(claimUID) {
+	case BlockModeTimeout:
+		klog.Warningf("Blocking claim %s until caller times out (test mode)", claimUID)
+		<-ctx.Done()
+		return faultError
+	case BlockModeReturnError:
+		return faultError
+	case BlockModePanic:
+		panic(fmt.Sprintf("injected panic for claim %s (test mode)", claimUID))
+	case BlockModeReturnEmpty:
+		return faultEmpty
+	default:
+		return faultNone
+	}
+}
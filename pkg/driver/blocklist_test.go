@@ -0,0 +1,121 @@
+package driver
+
+import "testing"
+
+func TestParseBlockMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    BlockMode
+		wantErr bool
+	}{
+		{in: "timeout", want: BlockModeTimeout},
+		{in: "Error", want: BlockModeReturnError},
+		{in: "PANIC", want: BlockModePanic},
+		{in: "empty", want: BlockModeReturnEmpty},
+		{in: "none", want: BlockModeNone},
+		{in: "", want: BlockModeNone},
+		{in: "  none  ", want: BlockModeNone},
+		{in: "bogus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseBlockMode(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBlockMode(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseBlockMode(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBlockClaimUIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]BlockMode
+		wantErr bool
+	}{
+		{
+			name: "empty string",
+			in:   "",
+			want: map[string]BlockMode{},
+		},
+		{
+			name: "single entry",
+			in:   "uid-1=timeout",
+			want: map[string]BlockMode{"uid-1": BlockModeTimeout},
+		},
+		{
+			name: "multiple entries",
+			in:   "uid-1=timeout,uid-2=panic,uid-3=empty",
+			want: map[string]BlockMode{
+				"uid-1": BlockModeTimeout,
+				"uid-2": BlockModePanic,
+				"uid-3": BlockModeReturnEmpty,
+			},
+		},
+		{
+			name:    "missing mode",
+			in:      "uid-1",
+			wantErr: true,
+		},
+		{
+			name:    "missing claim UID",
+			in:      "=timeout",
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			in:      "uid-1=bogus",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBlockClaimUIDs(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBlockClaimUIDs(%q): %v", tt.in, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for uid, mode := range tt.want {
+				if got[uid] != mode {
+					t.Fatalf("got[%q] = %v, want %v", uid, got[uid], mode)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockListSetClearsOnNone(t *testing.T) {
+	b := newBlockList()
+	b.set("uid-1", BlockModeTimeout)
+	if got := b.get("uid-1"); got != BlockModeTimeout {
+		t.Fatalf("get() = %v, want %v", got, BlockModeTimeout)
+	}
+
+	b.set("uid-1", BlockModeNone)
+	if got := b.get("uid-1"); got != BlockModeNone {
+		t.Fatalf("get() after clearing = %v, want %v", got, BlockModeNone)
+	}
+	if _, ok := b.modes["uid-1"]; ok {
+		t.Fatal("expected uid-1 to be removed from modes, not just set to none")
+	}
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	drapb "k8s.io/kubelet/pkg/apis/dra/v1"
+	drapbv1alpha2 "k8s.io/kubelet/pkg/apis/dra/v1alpha2"
+	drapbv1alpha3 "k8s.io/kubelet/pkg/apis/dra/v1alpha3"
+	drapbv1beta1 "k8s.io/kubelet/pkg/apis/dra/v1beta1"
+)
+
+// SupportedVersions is the full set of kubelet plugin registration version
+// strings this driver handles, oldest first. A registration server should
+// advertise all of them so one driver binary works across kubelet versions
+// from 1.27 through current.
+var SupportedVersions = []string{
+	"v1alpha2.Node",
+	"v1alpha3.Node",
+	"v1beta1.DRAPlugin",
+	"v1.DRAPlugin",
+}
+
+// registerCompatServers registers the older DRA gRPC services alongside the
+// current v1.DRAPlugin service on the same gRPC server, so kubelets that
+// haven't been upgraded yet can still talk to this driver. Each adapter
+// translates its version's request/response shape into a call against
+// prepareOne/unprepareOne, the same per-claim codepath the v1 service uses.
+func (d *Driver) registerCompatServers(server *grpc.Server) {
+	drapbv1alpha2.RegisterNodeServer(server, &v1alpha2Server{driver: d})
+	drapbv1alpha3.RegisterNodeServer(server, &v1alpha3Server{driver: d})
+	drapbv1beta1.RegisterDRAPluginServer(server, &v1beta1Server{driver: d})
+}
+
+// v1beta1Server adapts the v1beta1 DRAPlugin service, which is already
+// batched and shaped like v1, to prepareOne/unprepareOne.
+type v1beta1Server struct {
+	drapbv1beta1.UnimplementedDRAPluginServer
+	driver *Driver
+}
+
+func (s *v1beta1Server) NodePrepareResources(ctx context.Context, req *drapbv1beta1.NodePrepareResourcesRequest) (*drapbv1beta1.NodePrepareResourcesResponse, error) {
+	resp := &drapbv1beta1.NodePrepareResourcesResponse{
+		Claims: make(map[string]*drapbv1beta1.NodePrepareResourceResponse),
+	}
+	for _, claim := range req.Claims {
+		r := s.driver.prepareOne(ctx, &drapb.Claim{Uid: claim.Uid, Name: claim.Name, Namespace: claim.Namespace}, "")
+		if r == nil {
+			continue
+		}
+		resp.Claims[claim.Uid] = &drapbv1beta1.NodePrepareResourceResponse{
+			Devices: convertDevicesToV1beta1(r.Devices),
+			Error:   r.Error,
+		}
+	}
+	return resp, nil
+}
+
+func (s *v1beta1Server) NodeUnprepareResources(ctx context.Context, req *drapbv1beta1.NodeUnprepareResourcesRequest) (*drapbv1beta1.NodeUnprepareResourcesResponse, error) {
+	resp := &drapbv1beta1.NodeUnprepareResourcesResponse{
+		Claims: make(map[string]*drapbv1beta1.NodeUnprepareResourceResponse),
+	}
+	for _, claim := range req.Claims {
+		r := s.driver.unprepareOne(ctx, claim.Uid)
+		if r == nil {
+			continue
+		}
+		resp.Claims[claim.Uid] = &drapbv1beta1.NodeUnprepareResourceResponse{Error: r.Error}
+	}
+	return resp, nil
+}
+
+// v1alpha3Server adapts the v1alpha3 Node service, still batched and
+// Claim-shaped like v1, to prepareOne/unprepareOne.
+type v1alpha3Server struct {
+	drapbv1alpha3.UnimplementedNodeServer
+	driver *Driver
+}
+
+func (s *v1alpha3Server) NodePrepareResources(ctx context.Context, req *drapbv1alpha3.NodePrepareResourcesRequest) (*drapbv1alpha3.NodePrepareResourcesResponse, error) {
+	resp := &drapbv1alpha3.NodePrepareResourcesResponse{
+		Claims: make(map[string]*drapbv1alpha3.NodePrepareResourceResponse),
+	}
+	for _, claim := range req.Claims {
+		r := s.driver.prepareOne(ctx, &drapb.Claim{Uid: claim.Uid, Name: claim.Name, Namespace: claim.Namespace}, "")
+		if r == nil {
+			continue
+		}
+		resp.Claims[claim.Uid] = &drapbv1alpha3.NodePrepareResourceResponse{
+			Devices: convertDevicesToV1alpha3(r.Devices),
+			Error:   r.Error,
+		}
+	}
+	return resp, nil
+}
+
+func (s *v1alpha3Server) NodeUnprepareResources(ctx context.Context, req *drapbv1alpha3.NodeUnprepareResourcesRequest) (*drapbv1alpha3.NodeUnprepareResourcesResponse, error) {
+	resp := &drapbv1alpha3.NodeUnprepareResourcesResponse{
+		Claims: make(map[string]*drapbv1alpha3.NodeUnprepareResourceResponse),
+	}
+	for _, claim := range req.Claims {
+		r := s.driver.unprepareOne(ctx, claim.Uid)
+		if r == nil {
+			continue
+		}
+		resp.Claims[claim.Uid] = &drapbv1alpha3.NodeUnprepareResourceResponse{Error: r.Error}
+	}
+	return resp, nil
+}
+
+// v1alpha2Server adapts the pre-structured-parameters v1alpha2 Node
+// service, which prepares one claim per RPC and carries the claim's
+// driver-specific configuration inline as an opaque ResourceHandle string
+// rather than through the allocation result, to prepareOne/unprepareOne.
+type v1alpha2Server struct {
+	drapbv1alpha2.UnimplementedNodeServer
+	driver *Driver
+}
+
+func (s *v1alpha2Server) NodePrepareResource(ctx context.Context, req *drapbv1alpha2.NodePrepareResourceRequest) (*drapbv1alpha2.NodePrepareResourceResponse, error) {
+	claim := &drapb.Claim{Uid: req.ClaimUid, Name: req.ClaimName, Namespace: req.Namespace}
+	r := s.driver.prepareOne(ctx, claim, req.ResourceHandle)
+	if r == nil {
+		return &drapbv1alpha2.NodePrepareResourceResponse{}, nil
+	}
+	return &drapbv1alpha2.NodePrepareResourceResponse{
+		CdiDevices: flattenCDIDeviceIDs(r.Devices),
+		Error:      r.Error,
+	}, nil
+}
+
+func (s *v1alpha2Server) NodeUnprepareResource(ctx context.Context, req *drapbv1alpha2.NodeUnprepareResourceRequest) (*drapbv1alpha2.NodeUnprepareResourceResponse, error) {
+	r := s.driver.unprepareOne(ctx, req.ClaimUid)
+	if r == nil {
+		return &drapbv1alpha2.NodeUnprepareResourceResponse{}, nil
+	}
+	return &drapbv1alpha2.NodeUnprepareResourceResponse{Error: r.Error}, nil
+}
+
+func convertDevicesToV1beta1(devices []*drapb.Device) []*drapbv1beta1.Device {
+	out := make([]*drapbv1beta1.Device, 0, len(devices))
+	for _, dev := range devices {
+		out = append(out, &drapbv1beta1.Device{
+			PoolName:     dev.PoolName,
+			DeviceName:   dev.DeviceName,
+			CdiDeviceIds: dev.CdiDeviceIds,
+		})
+	}
+	return out
+}
+
+func convertDevicesToV1alpha3(devices []*drapb.Device) []*drapbv1alpha3.Device {
+	out := make([]*drapbv1alpha3.Device, 0, len(devices))
+	for _, dev := range devices {
+		out = append(out, &drapbv1alpha3.Device{
+			PoolName:     dev.PoolName,
+			DeviceName:   dev.DeviceName,
+			CdiDeviceIds: dev.CdiDeviceIds,
+		})
+	}
+	return out
+}
+
+// flattenCDIDeviceIDs collects every CDI device ID across devices, since the
+// v1alpha2 API returned a single flat list rather than per-device entries.
+func flattenCDIDeviceIDs(devices []*drapb.Device) []string {
+	var ids []string
+	for _, dev := range devices {
+		ids = append(ids, dev.CdiDeviceIds...)
+	}
+	return ids
+}
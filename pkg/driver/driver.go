@@ -2,7 +2,6 @@ package driver
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -11,64 +10,125 @@ import (
 	"sync"
 
 	"google.golang.org/grpc"
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	drapb "k8s.io/kubelet/pkg/apis/dra/v1"
+
+	"tags.cncf.io/container-device-interface/pkg/cdi"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
+
+	"github.com/example/dra-poc/pkg/driver/state"
 )
 
 const (
 	resourceFileName = "file1"
-	cdiDir           = "/var/run/cdi"
-	cdiVersion       = "0.5.0"
+	cdiVersion       = cdispecs.CurrentVersion
+
+	// checkpointFileName lives next to the plugin socket, mirroring where
+	// kubelet itself keeps a plugin's local state.
+	checkpointFileName = "dra_checkpoint.json"
 )
 
-// Driver implements the DRA plugin interface
+// Driver implements the DRA plugin interface. It hosts a single PluginConfig
+// and delegates all backing-file work to cfg.FileOps, so the same Driver
+// code serves any kind of device file, not just the built-in one.
 type Driver struct {
 	drapb.UnimplementedDRAPluginServer
 
-	driverName   string
-	nodeName     string
-	pluginSocket string
-	resourceDir  string
+	cfg      PluginConfig
+	cdiDir   string
+	cdiCache *cdi.Cache
 
 	server *grpc.Server
 	mu     sync.Mutex
 
-	// Track which pods are using resources
-	podResources map[string]string // claimUID -> podName
+	checkpointer *state.Checkpointer
+
+	// claims mirrors the checkpoint file and is the source of truth for
+	// which claims this driver currently has resources prepared for.
+	claims map[string]state.ClaimInfo // claimUID -> ClaimInfo
+
+	// blocked holds e2e-test fault injection configured via
+	// SetBlockedClaim, the --block-claim-uids flag, or the admin endpoint.
+	blocked *blockList
 }
 
-// New creates a new DRA driver instance
+// New creates a new DRA driver instance using the original single-file,
+// read-only bind mount behavior. Kept for existing callers; new code should
+// call StartPlugin with a PluginConfig instead.
 func New(driverName, nodeName, pluginSocket, resourceDir string) (*Driver, error) {
-	return &Driver{
-		driverName:   driverName,
-		nodeName:     nodeName,
-		pluginSocket: pluginSocket,
-		resourceDir:  resourceDir,
-		podResources: make(map[string]string),
-	}, nil
+	cfg := PluginConfig{
+		DriverName:   driverName,
+		NodeName:     nodeName,
+		PluginSocket: pluginSocket,
+		ResourceDir:  resourceDir,
+		DeviceName:   resourceFileName,
+		FileOps:      DefaultFileOperations(),
+	}
+	return newDriver(cfg, "/var/run/cdi")
+}
+
+// newDriver builds a Driver from an already-defaulted PluginConfig, loading
+// any claims a previous instance of this driver checkpointed to disk.
+func newDriver(cfg PluginConfig, cdiDir string) (*Driver, error) {
+	checkpointer, err := state.NewCheckpointer(filepath.Dir(cfg.PluginSocket), checkpointFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpointer: %w", err)
+	}
+
+	claims, err := checkpointer.GetOrCreate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if len(claims) > 0 {
+		klog.Infof("Restored %d claim(s) from checkpoint for driver %s", len(claims), cfg.DriverName)
+	}
+
+	if err := os.MkdirAll(cdiDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create CDI directory: %w", err)
+	}
+	cdiCache, err := cdi.NewCache(cdi.WithSpecDirs(cdiDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CDI cache: %w", err)
+	}
+
+	d := &Driver{
+		cfg:          cfg,
+		cdiDir:       cdiDir,
+		cdiCache:     cdiCache,
+		checkpointer: checkpointer,
+		claims:       claims,
+		blocked:      newBlockList(),
+	}
+	for claimUID, mode := range cfg.InitialBlockedClaims {
+		d.SetBlockedClaim(claimUID, mode)
+	}
+	return d, nil
 }
 
 // Start starts the gRPC server
 func (d *Driver) Start(ctx context.Context) error {
 	// Remove existing socket file if it exists
-	socketDir := filepath.Dir(d.pluginSocket)
+	socketDir := filepath.Dir(d.cfg.PluginSocket)
 	if err := os.MkdirAll(socketDir, 0755); err != nil {
 		return fmt.Errorf("failed to create socket directory: %w", err)
 	}
 
-	if err := os.Remove(d.pluginSocket); err != nil && !os.IsNotExist(err) {
+	if err := os.Remove(d.cfg.PluginSocket); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove existing socket: %w", err)
 	}
 
-	listener, err := net.Listen("unix", d.pluginSocket)
+	listener, err := net.Listen("unix", d.cfg.PluginSocket)
 	if err != nil {
 		return fmt.Errorf("failed to listen on socket: %w", err)
 	}
 
 	d.server = grpc.NewServer()
 	drapb.RegisterDRAPluginServer(d.server, d)
+	d.registerCompatServers(d.server)
 
-	klog.Infof("DRA driver listening on %s", d.pluginSocket)
+	klog.Infof("DRA driver %s listening on %s", d.cfg.DriverName, d.cfg.PluginSocket)
 
 	// Run server in goroutine
 	errCh := make(chan error, 1)
@@ -80,7 +140,7 @@ func (d *Driver) Start(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		klog.Info("Shutting down gRPC server")
+		klog.Infof("Shutting down gRPC server for %s", d.cfg.DriverName)
 		d.server.GracefulStop()
 		return nil
 	case err := <-errCh:
@@ -88,6 +148,17 @@ func (d *Driver) Start(ctx context.Context) error {
 	}
 }
 
+// logStartError reports a background Start failure with the driver name
+// attached, since StartPlugin runs Start in a goroutine it doesn't wait on.
+func (d *Driver) logStartError(err error) {
+	klog.Errorf("Driver %s failed: %v", d.cfg.DriverName, err)
+}
+
+// hostPath returns where this driver's single device file lives.
+func (d *Driver) hostPath() string {
+	return filepath.Join(d.cfg.ResourceDir, d.cfg.DeviceName)
+}
+
 // NodePrepareResources prepares resources for a pod
 func (d *Driver) NodePrepareResources(ctx context.Context, req *drapb.NodePrepareResourcesRequest) (*drapb.NodePrepareResourcesResponse, error) {
 	klog.Infof("NodePrepareResources called with %d claims", len(req.Claims))
@@ -97,43 +168,9 @@ func (d *Driver) NodePrepareResources(ctx context.Context, req *drapb.NodePrepar
 	}
 
 	for _, claim := range req.Claims {
-		klog.Infof("Preparing resource for claim: %s, namespace: %s, pod: %s/%s",
-			claim.Uid, claim.Namespace, claim.Namespace, claim.Name)
-
-		// Get pod name from the claim - in DRA, the pod info comes from structured parameters
-		// For this demo, we'll extract it from the claim name or use a placeholder
-		podName := d.extractPodName(claim)
-
-		if err := d.prepareResource(claim.Uid, podName); err != nil {
-			klog.Errorf("Failed to prepare resource for claim %s: %v", claim.Uid, err)
-			resp.Claims[claim.Uid] = &drapb.NodePrepareResourceResponse{
-				Error: err.Error(),
-			}
-			continue
+		if r := d.prepareOne(ctx, claim, ""); r != nil {
+			resp.Claims[claim.Uid] = r
 		}
-
-		// Create CDI spec for this claim
-		cdiDeviceID := fmt.Sprintf("%s/file=%s", d.driverName, resourceFileName)
-		if err := d.createCDISpec(claim.Uid); err != nil {
-			klog.Errorf("Failed to create CDI spec for claim %s: %v", claim.Uid, err)
-			resp.Claims[claim.Uid] = &drapb.NodePrepareResourceResponse{
-				Error: err.Error(),
-			}
-			continue
-		}
-
-		// Return CDI device ID - containerd will use this to inject the file mount
-		resp.Claims[claim.Uid] = &drapb.NodePrepareResourceResponse{
-			Devices: []*drapb.Device{
-				{
-					PoolName:     "default",
-					DeviceName:   resourceFileName,
-					CdiDeviceIds: []string{cdiDeviceID},
-				},
-			},
-		}
-
-		klog.Infof("Successfully prepared resource for claim %s, pod %s, CDI device: %s", claim.Uid, podName, cdiDeviceID)
 	}
 
 	return resp, nil
@@ -148,186 +185,361 @@ func (d *Driver) NodeUnprepareResources(ctx context.Context, req *drapb.NodeUnpr
 	}
 
 	for _, claim := range req.Claims {
-		klog.Infof("Unpreparing resource for claim: %s", claim.Uid)
+		if r := d.unprepareOne(ctx, claim.Uid); r != nil {
+			resp.Claims[claim.Uid] = r
+		}
+	}
 
-		if err := d.unprepareResource(claim.Uid); err != nil {
-			klog.Errorf("Failed to unprepare resource for claim %s: %v", claim.Uid, err)
-			resp.Claims[claim.Uid] = &drapb.NodeUnprepareResourceResponse{
-				Error: err.Error(),
-			}
-			continue
+	return resp, nil
+}
+
+// prepareOne prepares a single claim and is shared by the current batch
+// NodePrepareResources and the legacy per-claim adapters in compat.go. The
+// claim's ClaimParameters are always resolved first; if contentOverride is
+// non-empty, it then replaces their Content and is recorded as the claim's
+// resource handle, which is how a pre-structured-parameters caller's
+// ResourceHandle string reaches the resource file.
+func (d *Driver) prepareOne(ctx context.Context, claim *drapb.Claim, contentOverride string) *drapb.NodePrepareResourceResponse {
+	klog.Infof("Preparing resource for claim: %s, namespace: %s, pod: %s/%s",
+		claim.Uid, claim.Namespace, claim.Namespace, claim.Name)
+
+	switch d.injectFault(ctx, claim.Uid) {
+	case faultError:
+		return &drapb.NodePrepareResourceResponse{
+			Error: fmt.Sprintf("injected error for claim %s (test mode)", claim.Uid),
 		}
+	case faultEmpty:
+		return nil
+	}
 
-		// Delete CDI spec
-		if err := d.deleteCDISpec(claim.Uid); err != nil {
-			klog.Warningf("Failed to delete CDI spec for claim %s: %v", claim.Uid, err)
+	params, className, resourceHandle, podUIDs, err := d.claimParameters(ctx, claim)
+	if err != nil {
+		klog.Errorf("Failed to resolve claim parameters for %s: %v", claim.Uid, err)
+		return &drapb.NodePrepareResourceResponse{Error: err.Error()}
+	}
+	if contentOverride != "" {
+		params.Content = contentOverride
+		resourceHandle = contentOverride
+	}
+
+	if err := d.prepareResource(claim.Uid, params); err != nil {
+		klog.Errorf("Failed to prepare resource for claim %s: %v", claim.Uid, err)
+		return &drapb.NodePrepareResourceResponse{Error: err.Error()}
+	}
+
+	// Create CDI spec for this claim
+	cdiDeviceID := fmt.Sprintf("%s/file=%s", d.cfg.DriverName, d.cfg.DeviceName)
+	specName, err := d.createCDISpec(claim, params)
+	if err != nil {
+		klog.Errorf("Failed to create CDI spec for claim %s: %v", claim.Uid, err)
+		return &drapb.NodePrepareResourceResponse{Error: err.Error()}
+	}
+
+	if err := d.recordClaim(claim, podUIDs, className, resourceHandle, []string{cdiDeviceID}, specName); err != nil {
+		klog.Errorf("Failed to checkpoint claim %s: %v", claim.Uid, err)
+		return &drapb.NodePrepareResourceResponse{Error: err.Error()}
+	}
+
+	klog.Infof("Successfully prepared resource for claim %s, CDI device: %s", claim.Uid, cdiDeviceID)
+
+	// Return CDI device ID - containerd will use this to inject the file mount
+	return &drapb.NodePrepareResourceResponse{
+		Devices: []*drapb.Device{
+			{
+				PoolName:     "default",
+				DeviceName:   d.cfg.DeviceName,
+				CdiDeviceIds: []string{cdiDeviceID},
+			},
+		},
+	}
+}
+
+// unprepareOne unprepares a single claim and is shared by the current batch
+// NodeUnprepareResources and the legacy per-claim adapters in compat.go.
+func (d *Driver) unprepareOne(ctx context.Context, claimUID string) *drapb.NodeUnprepareResourceResponse {
+	klog.Infof("Unpreparing resource for claim: %s", claimUID)
+
+	switch d.injectFault(ctx, claimUID) {
+	case faultError:
+		return &drapb.NodeUnprepareResourceResponse{
+			Error: fmt.Sprintf("injected error for claim %s (test mode)", claimUID),
 		}
+	case faultEmpty:
+		return nil
+	}
+
+	d.mu.Lock()
+	specName := d.claims[claimUID].CDISpecName
+	d.mu.Unlock()
 
-		resp.Claims[claim.Uid] = &drapb.NodeUnprepareResourceResponse{}
-		klog.Infof("Successfully unprepared resource for claim %s", claim.Uid)
+	if err := d.unprepareResource(claimUID); err != nil {
+		klog.Errorf("Failed to unprepare resource for claim %s: %v", claimUID, err)
+		return &drapb.NodeUnprepareResourceResponse{Error: err.Error()}
 	}
 
-	return resp, nil
+	// Delete CDI spec
+	if err := d.deleteCDISpec(specName); err != nil {
+		klog.Warningf("Failed to delete CDI spec for claim %s: %v", claimUID, err)
+	}
+
+	klog.Infof("Successfully unprepared resource for claim %s", claimUID)
+	return &drapb.NodeUnprepareResourceResponse{}
 }
 
-// extractPodName extracts the pod name from claim information
-func (d *Driver) extractPodName(claim *drapb.Claim) string {
-	// In a real implementation, this would come from structured parameters
-	// or the ResourceClaimParameters. For this demo, we'll construct a meaningful name.
-	if claim.Name != "" {
-		return fmt.Sprintf("pod-using-%s", claim.Name)
+// claimParameters resolves this driver's ClaimParameters for claim by
+// looking up its opaque device configuration through cfg.ClaimClient, along
+// with the device class name the claim's allocation satisfied, the raw
+// opaque configuration that produced the parameters, and the UIDs of the
+// pods currently reserving the claim — all recorded alongside the claim's
+// checkpoint entry by recordClaim. If no claim client is configured, it falls
+// back to a generated placeholder so the driver still works without API
+// server access (e.g. in local testing), and returns no class name, resource
+// handle, or pod UIDs. If the claim carries no configuration for this
+// driver, it also falls back to the placeholder and returns no resource
+// handle, but still returns the class name and pod UIDs it found.
+func (d *Driver) claimParameters(ctx context.Context, claim *drapb.Claim) (params ClaimParameters, className, resourceHandle string, podUIDs []string, err error) {
+	if d.cfg.ClaimClient == nil || claim.Namespace == "" || claim.Name == "" {
+		return ClaimParameters{Content: fallbackContent(claim)}, "", "", nil, nil
+	}
+
+	rc, err := d.cfg.ClaimClient.ResourceClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
+	if err != nil {
+		return ClaimParameters{}, "", "", nil, fmt.Errorf("failed to get ResourceClaim %s/%s: %w", claim.Namespace, claim.Name, err)
+	}
+	podUIDs = reservedForUIDs(rc)
+
+	if rc.Status.Allocation != nil {
+		className = deviceClassName(rc, d.cfg.DriverName)
+
+		for _, deviceCfg := range rc.Status.Allocation.Devices.Config {
+			if deviceCfg.Opaque == nil || deviceCfg.Opaque.Driver != d.cfg.DriverName {
+				continue
+			}
+			params, err = decodeClaimParameters(deviceCfg.Opaque.Parameters.Raw)
+			if err != nil {
+				return ClaimParameters{}, "", "", nil, err
+			}
+			if params.Content == "" {
+				params.Content = fallbackContent(claim)
+			}
+			return params, className, string(deviceCfg.Opaque.Parameters.Raw), podUIDs, nil
+		}
 	}
-	return fmt.Sprintf("pod-%s", claim.Uid[:8])
+
+	return ClaimParameters{Content: fallbackContent(claim)}, className, "", podUIDs, nil
 }
 
-// prepareResource writes the pod name to the resource file
-func (d *Driver) prepareResource(claimUID, podName string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// deviceClassName returns the DeviceClassName of the request that the
+// allocation result this driver satisfied came from. A claim can span
+// several requests against different classes, but this driver only ever
+// allocates a single device per claim, so the first match is enough.
+func deviceClassName(rc *resourceapi.ResourceClaim, driverName string) string {
+	for _, result := range rc.Status.Allocation.Devices.Results {
+		if result.Driver != driverName {
+			continue
+		}
+		for _, req := range rc.Spec.Devices.Requests {
+			if req.Name == result.Request {
+				return req.DeviceClassName
+			}
+		}
+	}
+	return ""
+}
 
-	filePath := filepath.Join(d.resourceDir, resourceFileName)
+// reservedForUIDs returns the UIDs of the pods currently reserving claim.
+// drapb.Claim itself carries no pod identity once parameters became
+// structured, so this ResourceClaim lookup is the only remaining source for
+// ClaimInfo.PodUIDs.
+func reservedForUIDs(rc *resourceapi.ResourceClaim) []string {
+	if len(rc.Status.ReservedFor) == 0 {
+		return nil
+	}
+	uids := make([]string, 0, len(rc.Status.ReservedFor))
+	for _, ref := range rc.Status.ReservedFor {
+		uids = append(uids, string(ref.UID))
+	}
+	return uids
+}
 
-	// Write pod name to file (overwrite)
-	content := fmt.Sprintf("%s (claim: %s)\n", podName, claimUID)
+// fallbackContent generates a placeholder for claims that don't carry any
+// ClaimParameters, so the resource file is never left empty.
+func fallbackContent(claim *drapb.Claim) string {
+	if claim.Name != "" {
+		return fmt.Sprintf("claim-%s", claim.Name)
+	}
+	return fmt.Sprintf("claim-%s", claim.Uid[:8])
+}
 
-	// Write to file
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write resource file: %w", err)
+// prepareResource delegates to cfg.FileOps.Create to produce the backing
+// file for claimUID, then applies params.Mode to it.
+func (d *Driver) prepareResource(claimUID string, params ClaimParameters) error {
+	if err := d.cfg.FileOps.Create(claimUID, params.Content, d.hostPath()); err != nil {
+		return fmt.Errorf("failed to create resource file: %w", err)
 	}
 
-	// Track the pod
-	d.podResources[claimUID] = podName
+	mode, err := params.fileMode()
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(d.hostPath(), mode); err != nil {
+		return fmt.Errorf("failed to set resource file mode: %w", err)
+	}
 
-	klog.Infof("Wrote pod name '%s' to %s", podName, filePath)
 	return nil
 }
 
-// unprepareResource removes the pod name from the resource file
+// unprepareResource delegates to cfg.FileOps.Remove to clean up the backing
+// file for claimUID. It relies solely on the checkpointed claim state, not
+// the API server, to know whether there's anything to clean up.
 func (d *Driver) unprepareResource(claimUID string) error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	_, ok := d.claims[claimUID]
+	d.mu.Unlock()
 
-	podName, ok := d.podResources[claimUID]
 	if !ok {
-		klog.Warningf("No tracked pod for claim %s", claimUID)
+		klog.Warningf("No tracked claim %s", claimUID)
 		return nil
 	}
 
-	filePath := filepath.Join(d.resourceDir, resourceFileName)
+	if err := d.cfg.FileOps.Remove(claimUID, d.hostPath()); err != nil {
+		return fmt.Errorf("failed to remove resource file: %w", err)
+	}
+
+	return d.forgetClaim(claimUID)
+}
+
+// recordClaim saves claim's ClaimInfo in memory and atomically updates the
+// on-disk checkpoint before NodePrepareResources responds, so a driver
+// restart can rebuild this state without calling back to the API server.
+func (d *Driver) recordClaim(claim *drapb.Claim, podUIDs []string, className, resourceHandle string, cdiDeviceIDs []string, cdiSpecName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.claims == nil {
+		d.claims = make(map[string]state.ClaimInfo)
+	}
+	d.claims[claim.Uid] = state.ClaimInfo{
+		DriverName:     d.cfg.DriverName,
+		ClassName:      className,
+		ClaimUID:       claim.Uid,
+		ClaimName:      claim.Name,
+		Namespace:      claim.Namespace,
+		PodUIDs:        podUIDs,
+		CDIDeviceIDs:   cdiDeviceIDs,
+		CDISpecName:    cdiSpecName,
+		ResourceHandle: resourceHandle,
+	}
+
+	return d.checkpointer.Store(d.claims)
+}
+
+// forgetClaim removes claimUID from memory and atomically updates the
+// on-disk checkpoint before NodeUnprepareResources responds.
+func (d *Driver) forgetClaim(claimUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.claims, claimUID)
+	return d.checkpointer.Store(d.claims)
+}
+
+// writeContent is the Create half of DefaultFileOperations: it overwrites
+// hostPath with a single "<content> (claim: <claimUID>)" line.
+func writeContent(hostPath, claimUID, content string) error {
+	line := fmt.Sprintf("%s (claim: %s)\n", content, claimUID)
+	if err := os.WriteFile(hostPath, []byte(line), 0644); err != nil {
+		return err
+	}
+	klog.Infof("Wrote content for claim %s to %s", claimUID, hostPath)
+	return nil
+}
 
-	// Read existing content
-	data, err := os.ReadFile(filePath)
+// removeContent is the Remove half of DefaultFileOperations: it drops any
+// line referencing claimUID from hostPath.
+func removeContent(hostPath, claimUID string) error {
+	data, err := os.ReadFile(hostPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return fmt.Errorf("failed to read resource file: %w", err)
+		return err
 	}
 
-	// Remove the pod entry
 	lines := strings.Split(string(data), "\n")
 	var newLines []string
-	searchPattern := fmt.Sprintf("%s (claim: %s)", podName, claimUID)
-
 	for _, line := range lines {
-		if line != "" && line != searchPattern {
+		if line != "" && !strings.Contains(line, fmt.Sprintf("(claim: %s)", claimUID)) {
 			newLines = append(newLines, line)
 		}
 	}
 
-	// Write back
 	newContent := strings.Join(newLines, "\n")
 	if newContent != "" && !strings.HasSuffix(newContent, "\n") {
 		newContent += "\n"
 	}
 
-	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("failed to write resource file: %w", err)
+	if err := os.WriteFile(hostPath, []byte(newContent), 0644); err != nil {
+		return err
 	}
 
-	// Remove tracking
-	delete(d.podResources, claimUID)
-
-	klog.Infof("Removed pod name '%s' from %s", podName, filePath)
+	klog.Infof("Removed claim %s entry from %s", claimUID, hostPath)
 	return nil
 }
 
-// CDI spec structures
-type cdiSpec struct {
-	CDIVersion string      `json:"cdiVersion"`
-	Kind       string      `json:"kind"`
-	Devices    []cdiDevice `json:"devices"`
-}
-
-type cdiDevice struct {
-	Name           string            `json:"name"`
-	ContainerEdits cdiContainerEdits `json:"containerEdits"`
-}
-
-type cdiContainerEdits struct {
-	Mounts []cdiMount `json:"mounts,omitempty"`
-}
-
-type cdiMount struct {
-	HostPath      string   `json:"hostPath"`
-	ContainerPath string   `json:"containerPath"`
-	Options       []string `json:"options,omitempty"`
-}
-
-// createCDISpec creates a CDI spec file for the claim
-func (d *Driver) createCDISpec(claimUID string) error {
-	// Ensure CDI directory exists
-	if err := os.MkdirAll(cdiDir, 0755); err != nil {
-		return fmt.Errorf("failed to create CDI directory: %w", err)
+// createCDISpec builds a CDI spec for the claim using the upstream specs-go
+// types, asking cfg.FileOps.Prepare for the ContainerEdits this device needs
+// (mounts, env vars, device nodes, hooks, or anything else the CDI schema
+// allows), then writes it through cdiCache so it's validated and named the
+// way CDI consumers (containerd, CRI-O) expect. It returns the generated
+// spec name so the caller can record it for later deletion.
+func (d *Driver) createCDISpec(claim *drapb.Claim, params ClaimParameters) (string, error) {
+	containerPath := d.hostPath()
+	if params.SubPath != "" {
+		containerPath = filepath.Join(filepath.Dir(d.hostPath()), params.SubPath)
 	}
 
-	filePath := filepath.Join(d.resourceDir, resourceFileName)
+	edits, err := d.cfg.FileOps.Prepare(claim, d.hostPath(), containerPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare CDI edits: %w", err)
+	}
 
-	spec := cdiSpec{
-		CDIVersion: cdiVersion,
-		Kind:       fmt.Sprintf("%s/file", d.driverName),
-		Devices: []cdiDevice{
+	spec := &cdispecs.Spec{
+		Version: cdiVersion,
+		Kind:    fmt.Sprintf("%s/file", d.cfg.DriverName),
+		Devices: []cdispecs.Device{
 			{
-				Name: resourceFileName,
-				ContainerEdits: cdiContainerEdits{
-					Mounts: []cdiMount{
-						{
-							HostPath:      filePath,
-							ContainerPath: filePath,
-							Options:       []string{"ro", "bind"},
-						},
-					},
-				},
+				Name:           d.cfg.DeviceName,
+				ContainerEdits: edits,
 			},
 		},
 	}
 
-	data, err := json.MarshalIndent(spec, "", "  ")
+	specName, err := cdi.GenerateNameForSpec(spec)
 	if err != nil {
-		return fmt.Errorf("failed to marshal CDI spec: %w", err)
+		return "", fmt.Errorf("failed to generate CDI spec name: %w", err)
 	}
 
-	// CDI spec filename: <driver>-<device>.json
-	cdiFileName := fmt.Sprintf("%s-file-%s.json", strings.ReplaceAll(d.driverName, "/", "-"), resourceFileName)
-	cdiFilePath := filepath.Join(cdiDir, cdiFileName)
-
-	if err := os.WriteFile(cdiFilePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write CDI spec: %w", err)
+	if err := d.cdiCache.WriteSpec(spec, specName); err != nil {
+		return "", fmt.Errorf("failed to write CDI spec: %w", err)
 	}
 
-	klog.Infof("Created CDI spec at %s", cdiFilePath)
-	return nil
+	klog.Infof("Created CDI spec %s in %s", specName, d.cdiDir)
+	return specName, nil
 }
 
-// deleteCDISpec removes the CDI spec file for the claim
-func (d *Driver) deleteCDISpec(claimUID string) error {
-	cdiFileName := fmt.Sprintf("%s-file-%s.json", strings.ReplaceAll(d.driverName, "/", "-"), resourceFileName)
-	cdiFilePath := filepath.Join(cdiDir, cdiFileName)
+// deleteCDISpec removes the CDI spec file previously written for specName.
+// It's a no-op if specName is empty, which happens for claims prepared
+// before this driver tracked spec names.
+func (d *Driver) deleteCDISpec(specName string) error {
+	if specName == "" {
+		return nil
+	}
 
-	if err := os.Remove(cdiFilePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete CDI spec: %w", err)
+	if err := d.cdiCache.RemoveSpec(specName); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete CDI spec %s: %w", specName, err)
 	}
 
-	klog.Infof("Deleted CDI spec at %s", cdiFilePath)
+	klog.Infof("Deleted CDI spec %s", specName)
 	return nil
 }
@@ -0,0 +1,276 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	resourceclient "k8s.io/client-go/kubernetes/typed/resource/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// maxDevicesPerSlice mirrors the kubelet NodeResourceSlice controller's
+// limit on how many devices a single ResourceSlice may hold. Pools larger
+// than this are split across several slices sharing one pool name.
+const maxDevicesPerSlice = 128
+
+// resourceSliceController reconciles the set of ResourceSlices owned by
+// this driver on this node against the desired device pool. It replaces the
+// fixed-interval create-then-update polling that used to live in
+// ResourcePublisher with an informer-driven workqueue, the same pattern the
+// kubelet's own NodeResourceSlice controller uses.
+type resourceSliceController struct {
+	client     resourceclient.ResourceV1Interface
+	driverName string
+	nodeName   string
+	ownerRef   *metav1.OwnerReference
+
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+
+	mu         sync.Mutex
+	desired    []resourceapi.Device
+	generation int64
+}
+
+// newResourceSliceController builds a controller that keeps the pool named
+// nodeName in sync with whatever devices are passed to setDesired.
+func newResourceSliceController(client resourceclient.ResourceV1Interface, driverName, nodeName string, ownerRef *metav1.OwnerReference) *resourceSliceController {
+	c := &resourceSliceController{
+		client:     client,
+		driverName: driverName,
+		nodeName:   nodeName,
+		ownerRef:   ownerRef,
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "resourceslices"),
+	}
+
+	c.informer = cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = fmt.Sprintf("spec.driver=%s", driverName)
+				return client.ResourceSlices().List(context.Background(), opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = fmt.Sprintf("spec.driver=%s", driverName)
+				return client.ResourceSlices().Watch(context.Background(), opts)
+			},
+		},
+		&resourceapi.ResourceSlice{},
+		reconcileResyncInterval,
+		cache.Indexers{},
+	)
+
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueue() },
+		UpdateFunc: func(old, new interface{}) { c.enqueue() },
+		DeleteFunc: func(obj interface{}) { c.enqueue() },
+	})
+
+	return c
+}
+
+func (c *resourceSliceController) enqueue() {
+	c.queue.Add(c.nodeName)
+}
+
+// setDesired replaces the device pool this controller should advertise and
+// triggers a reconcile.
+func (c *resourceSliceController) setDesired(devices []resourceapi.Device) {
+	c.mu.Lock()
+	c.desired = devices
+	c.generation++
+	c.mu.Unlock()
+	c.enqueue()
+}
+
+// Run starts the informer and processes the workqueue until ctx is done. It
+// waits for the worker goroutine to actually exit before returning, so a
+// caller that reconciles directly right after Run returns (e.g.
+// StartPublishing's teardown) never races it.
+func (c *resourceSliceController) Run(ctx context.Context) {
+	go c.informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		klog.Errorf("resourceSliceController for %s: cache never synced", c.driverName)
+		return
+	}
+
+	// Initial reconcile in case nothing is desired yet but stale slices
+	// from a previous run need to be cleaned up.
+	c.enqueue()
+
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for c.processNextItem(ctx) {
+		}
+	}()
+
+	<-ctx.Done()
+	c.queue.ShutDown()
+	<-workerDone
+}
+
+func (c *resourceSliceController) processNextItem(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(ctx); err != nil {
+		klog.Errorf("resourceSliceController: reconcile failed: %v", err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile compares the desired device pool against every ResourceSlice
+// this driver currently owns on this node: stray slices (no longer backed
+// by any desired device chunk) are deleted, existing slices are updated in
+// place via their resourceVersion, and missing chunks are created.
+func (c *resourceSliceController) reconcile(ctx context.Context) error {
+	c.mu.Lock()
+	desired := append([]resourceapi.Device(nil), c.desired...)
+	generation := c.generation
+	c.mu.Unlock()
+
+	existing, err := c.listOwned()
+	if err != nil {
+		return fmt.Errorf("failed to list existing ResourceSlices: %w", err)
+	}
+
+	chunks := chunkDevices(desired, maxDevicesPerSlice)
+	sliceCount := len(chunks)
+	if sliceCount == 0 {
+		sliceCount = 1
+	}
+
+	used := make(map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		name := c.sliceName(i)
+		used[name] = true
+
+		slice := &resourceapi.ResourceSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: resourceapi.ResourceSliceSpec{
+				Driver:   c.driverName,
+				NodeName: &c.nodeName,
+				Pool: resourceapi.ResourcePool{
+					Name:               c.nodeName,
+					Generation:         generation,
+					ResourceSliceCount: int64(sliceCount),
+				},
+				Devices: chunk,
+			},
+		}
+		if c.ownerRef != nil {
+			slice.OwnerReferences = []metav1.OwnerReference{*c.ownerRef}
+		}
+
+		if existingSlice, ok := existing[name]; ok {
+			slice.ResourceVersion = existingSlice.ResourceVersion
+			if _, err := c.client.ResourceSlices().Update(ctx, slice, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("failed to update ResourceSlice %s: %w", name, err)
+			}
+		} else {
+			if _, err := c.client.ResourceSlices().Create(ctx, slice, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed to create ResourceSlice %s: %w", name, err)
+			}
+		}
+	}
+
+	// Anything left over in `existing` is stray: it matches this driver's
+	// name but no longer corresponds to a desired chunk.
+	for name := range existing {
+		if used[name] {
+			continue
+		}
+		if err := c.client.ResourceSlices().Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("failed to delete stray ResourceSlice %s: %w", name, err)
+		}
+		klog.Infof("Deleted stray ResourceSlice %s for driver %s", name, c.driverName)
+	}
+
+	return nil
+}
+
+// listOwned returns the ResourceSlices the informer has cached for this
+// driver and node, keyed by name.
+func (c *resourceSliceController) listOwned() (map[string]*resourceapi.ResourceSlice, error) {
+	out := make(map[string]*resourceapi.ResourceSlice)
+	for _, obj := range c.informer.GetStore().List() {
+		slice, ok := obj.(*resourceapi.ResourceSlice)
+		if !ok {
+			continue
+		}
+		if slice.Spec.Driver != c.driverName {
+			continue
+		}
+		if slice.Spec.NodeName == nil || *slice.Spec.NodeName != c.nodeName {
+			continue
+		}
+		out[slice.Name] = slice
+	}
+	return out, nil
+}
+
+// sliceName names the i'th slice in this driver's pool. A single-slice pool
+// keeps the original "<node>-<driver>" name for backward compatibility.
+func (c *resourceSliceController) sliceName(i int) string {
+	if i == 0 {
+		return fmt.Sprintf("%s-%s", c.nodeName, c.driverName)
+	}
+	return fmt.Sprintf("%s-%s-%d", c.nodeName, c.driverName, i)
+}
+
+// chunkDevices splits devices into groups of at most size, preserving
+// order, so a pool bigger than one ResourceSlice can hold still advertises
+// correctly as multiple slices sharing a pool name.
+func chunkDevices(devices []resourceapi.Device, size int) [][]resourceapi.Device {
+	if len(devices) == 0 {
+		return nil
+	}
+	var chunks [][]resourceapi.Device
+	for len(devices) > 0 {
+		n := size
+		if n > len(devices) {
+			n = len(devices)
+		}
+		chunks = append(chunks, devices[:n])
+		devices = devices[n:]
+	}
+	return chunks
+}
+
+// ownerReferenceForNode builds an owner reference pointing at the given
+// Node, so a node's ResourceSlices are garbage collected when it is.
+func ownerReferenceForNode(nodeName string, nodeUID types.UID) *metav1.OwnerReference {
+	if nodeUID == "" {
+		return nil
+	}
+	controller := true
+	return &metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       nodeName,
+		UID:        nodeUID,
+		Controller: &controller,
+	}
+}
+
+// reconcileResyncInterval is a slow safety-net resync layered on top of the
+// informer's event-driven reconciles, in case a watch silently drops events.
+const reconcileResyncInterval = 5 * time.Minute
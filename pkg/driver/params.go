@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// claimParametersAPIVersion is the version this driver's opaque device
+// configuration envelope speaks. Bump it, and keep decoding older versions
+// for a deprecation window, whenever the schema changes incompatibly.
+const claimParametersAPIVersion = "file.dra.example.com/v1alpha1"
+
+// ClaimParameters is this driver's configuration schema, delivered as the
+// opaque device configuration JSON attached to a claim's allocation result.
+// It replaces the earlier "pod-using-<name>" placeholder with real,
+// allocation-time configurability.
+type ClaimParameters struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Content is written to the device file for this claim.
+	Content string `json:"content,omitempty"`
+	// Mode is an optional octal file mode, e.g. "0644". Defaults to 0644.
+	Mode string `json:"mode,omitempty"`
+	// SubPath, if set, is where the device is mounted under the
+	// container path instead of at the device file's own name.
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// decodeClaimParameters unmarshals a claim's opaque configuration payload.
+// Empty input isn't an error: it just means the claim didn't request any
+// driver-specific configuration, and callers should fall back to a default.
+func decodeClaimParameters(raw []byte) (ClaimParameters, error) {
+	var params ClaimParameters
+	if len(raw) == 0 {
+		return params, nil
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return params, fmt.Errorf("failed to decode claim parameters: %w", err)
+	}
+	if params.APIVersion != "" && params.APIVersion != claimParametersAPIVersion {
+		return params, fmt.Errorf("unsupported claim parameters apiVersion %q (want %q)", params.APIVersion, claimParametersAPIVersion)
+	}
+	return params, nil
+}
+
+// fileMode parses Mode, defaulting to 0644 when unset.
+func (p ClaimParameters) fileMode() (os.FileMode, error) {
+	if p.Mode == "" {
+		return 0644, nil
+	}
+	parsed, err := strconv.ParseUint(p.Mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q: %w", p.Mode, err)
+	}
+	return os.FileMode(parsed), nil
+}
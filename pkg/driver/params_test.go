@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDecodeClaimParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    ClaimParameters
+		wantErr bool
+	}{
+		{
+			name: "empty input falls back to zero value",
+			raw:  "",
+			want: ClaimParameters{},
+		},
+		{
+			name: "full payload",
+			raw:  `{"apiVersion":"file.dra.example.com/v1alpha1","content":"hello","mode":"0600","subPath":"data"}`,
+			want: ClaimParameters{
+				APIVersion: claimParametersAPIVersion,
+				Content:    "hello",
+				Mode:       "0600",
+				SubPath:    "data",
+			},
+		},
+		{
+			name: "apiVersion omitted is accepted",
+			raw:  `{"content":"hello"}`,
+			want: ClaimParameters{Content: "hello"},
+		},
+		{
+			name:    "unsupported apiVersion is rejected",
+			raw:     `{"apiVersion":"file.dra.example.com/v2","content":"hello"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			raw:     `{not json`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeClaimParameters([]byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeClaimParameters: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimParametersFileMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{name: "default", mode: "", want: 0644},
+		{name: "explicit octal", mode: "0600", want: 0600},
+		{name: "invalid", mode: "not-octal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (ClaimParameters{Mode: tt.mode}).fileMode()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fileMode: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	resourceclient "k8s.io/client-go/kubernetes/typed/resource/v1"
+	drapb "k8s.io/kubelet/pkg/apis/dra/v1"
+
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
+)
+
+// FileOperations lets a PluginConfig customize how a single device's backing
+// file is produced and described, without forking Driver. A driver built on
+// top of this package implements these callbacks instead of reimplementing
+// NodePrepareResources/NodeUnprepareResources.
+type FileOperations struct {
+	// Create writes content, resolved from the claim's parameters, to
+	// hostPath for claimUID.
+	Create func(claimUID, content, hostPath string) error
+
+	// Remove cleans up whatever Create wrote at hostPath for claimUID.
+	Remove func(claimUID, hostPath string) error
+
+	// Prepare builds the CDI container edits that should be published for
+	// the device backed by hostPath, exposed inside the container at
+	// containerPath. It returns the upstream CDI ContainerEdits type
+	// directly, so a driver can attach a mount, env vars, device nodes,
+	// hooks, or IntelRdt/AdditionalGIDs edits, not just a bind mount.
+	Prepare func(claim *drapb.Claim, hostPath, containerPath string) (cdispecs.ContainerEdits, error)
+}
+
+// PluginConfig parameterizes a single DRA driver hosted by StartPlugin. A
+// binary that hosts several drivers calls StartPlugin once per PluginConfig.
+type PluginConfig struct {
+	DriverName   string
+	NodeName     string
+	PluginSocket string
+	ResourceDir  string
+	DeviceName   string
+	FileOps      FileOperations
+
+	// ClaimClient, if set, lets the driver look up a claim's allocation
+	// result to decode this driver's opaque ClaimParameters. Without it,
+	// NodePrepareResources falls back to a generated placeholder.
+	ClaimClient resourceclient.ResourceV1Interface
+
+	// InitialBlockedClaims seeds fault injection for specific claim UIDs at
+	// startup, e.g. from the --block-claim-uids flag. Use the running
+	// Driver's SetBlockedClaim to change it afterwards.
+	InitialBlockedClaims map[string]BlockMode
+}
+
+func (cfg PluginConfig) validate() error {
+	if cfg.DriverName == "" {
+		return fmt.Errorf("driver name is required")
+	}
+	if cfg.PluginSocket == "" {
+		return fmt.Errorf("plugin socket is required")
+	}
+	if cfg.DeviceName == "" {
+		return fmt.Errorf("device name is required")
+	}
+	if cfg.FileOps.Create == nil || cfg.FileOps.Remove == nil || cfg.FileOps.Prepare == nil {
+		return fmt.Errorf("file operations (Create, Remove, Prepare) are required")
+	}
+	return nil
+}
+
+// DefaultFileOperations returns the original single-file, read-only bind
+// mount behavior that this driver shipped with before it became pluggable.
+func DefaultFileOperations() FileOperations {
+	return FileOperations{
+		Create: func(claimUID, content, hostPath string) error {
+			return writeContent(hostPath, claimUID, content)
+		},
+		Remove: func(claimUID, hostPath string) error {
+			return removeContent(hostPath, claimUID)
+		},
+		Prepare: func(claim *drapb.Claim, hostPath, containerPath string) (cdispecs.ContainerEdits, error) {
+			return cdispecs.ContainerEdits{
+				Mounts: []*cdispecs.Mount{
+					{
+						HostPath:      hostPath,
+						ContainerPath: containerPath,
+						Options:       []string{"ro", "bind"},
+					},
+				},
+			}, nil
+		},
+	}
+}
+
+// Handle is a running instance of a plugin started by StartPlugin.
+type Handle struct {
+	driver *Driver
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Stop shuts down the plugin's gRPC server and waits for it to exit.
+func (h *Handle) Stop() {
+	h.cancel()
+	<-h.done
+}
+
+// Driver returns the underlying Driver, e.g. to wire up an AdminServer or
+// call SetBlockedClaim directly from test code.
+func (h *Handle) Driver() *Driver {
+	return h.driver
+}
+
+// StartPlugin starts a single DRA driver instance in the background and
+// returns a Handle that can be used to stop it. A process can call
+// StartPlugin more than once, with different PluginConfigs, to host several
+// DRA drivers behind one binary.
+func StartPlugin(ctx context.Context, cdiDir, driverName, nodeName string, cfg PluginConfig) (*Handle, error) {
+	cfg.DriverName = driverName
+	cfg.NodeName = nodeName
+	if cfg.DeviceName == "" {
+		cfg.DeviceName = resourceFileName
+	}
+	if cfg.FileOps.Create == nil && cfg.FileOps.Remove == nil && cfg.FileOps.Prepare == nil {
+		cfg.FileOps = DefaultFileOperations()
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid plugin config: %w", err)
+	}
+
+	d, err := newDriver(cfg, cdiDir)
+	if err != nil {
+		return nil, err
+	}
+
+	pluginCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		if err := d.Start(pluginCtx); err != nil {
+			d.logStartError(err)
+		}
+	}()
+
+	return &Handle{driver: d, cancel: cancel, done: done}, nil
+}
@@ -8,17 +8,18 @@ import (
 	resourceapi "k8s.io/api/resource/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	resourceclient "k8s.io/client-go/kubernetes/typed/resource/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
-// ResourcePublisher publishes available resources as ResourceSlices
+// ResourcePublisher publishes available resources as ResourceSlices, keeping
+// them in sync via a resourceSliceController instead of polling.
 type ResourcePublisher struct {
-	client       resourceclient.ResourceV1Interface
 	driverName   string
 	nodeName     string
 	resourceName string
+
+	controller *resourceSliceController
 }
 
 // NewResourcePublisher creates a new resource publisher
@@ -34,109 +35,64 @@ func NewResourcePublisher(driverName, nodeName, resourceName string) (*ResourceP
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
-	return &ResourcePublisher{
-		client:       clientset.ResourceV1(),
+	var ownerRef *metav1.OwnerReference
+	if node, err := clientset.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{}); err != nil {
+		klog.Warningf("Failed to look up node %s for ResourceSlice owner reference (will continue without one): %v", nodeName, err)
+	} else {
+		ownerRef = ownerReferenceForNode(nodeName, node.UID)
+	}
+
+	p := &ResourcePublisher{
 		driverName:   driverName,
 		nodeName:     nodeName,
 		resourceName: resourceName,
-	}, nil
-}
-
-// PublishResources creates or updates the ResourceSlice for this node
-func (p *ResourcePublisher) PublishResources(ctx context.Context) error {
-	sliceName := fmt.Sprintf("%s-%s", p.nodeName, p.driverName)
+		controller:   newResourceSliceController(clientset.ResourceV1(), driverName, nodeName, ownerRef),
+	}
+	p.controller.setDesired(p.defaultDevices())
 
-	// Each node has its own pool (pool name = node name)
-	poolName := p.nodeName
+	return p, nil
+}
 
-	// Create the ResourceSlice
-	slice := &resourceapi.ResourceSlice{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: sliceName,
-		},
-		Spec: resourceapi.ResourceSliceSpec{
-			Driver:   p.driverName,
-			NodeName: &p.nodeName,
-			Pool: resourceapi.ResourcePool{
-				Name:               poolName,
-				Generation:         1,
-				ResourceSliceCount: 1,
-			},
-			Devices: []resourceapi.Device{
-				{
-					Name: p.resourceName,
-					Attributes: map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
-						"file.dra.example.com/type": {
-							StringValue: stringPtr("file"),
-						},
-						"file.dra.example.com/filename": {
-							StringValue: stringPtr(p.resourceName),
-						},
-						"file.dra.example.com/path": {
-							StringValue: stringPtr("/etc/dra/" + p.resourceName),
-						},
-					},
+// defaultDevices is the single-device pool this driver shipped with before
+// Update let callers change it at runtime.
+func (p *ResourcePublisher) defaultDevices() []resourceapi.Device {
+	return []resourceapi.Device{
+		{
+			Name: p.resourceName,
+			Attributes: map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+				"file.dra.example.com/type": {
+					StringValue: stringPtr("file"),
+				},
+				"file.dra.example.com/filename": {
+					StringValue: stringPtr(p.resourceName),
+				},
+				"file.dra.example.com/path": {
+					StringValue: stringPtr("/etc/dra/" + p.resourceName),
 				},
 			},
 		},
 	}
-
-	// Try to create or update
-	_, err := p.client.ResourceSlices().Create(ctx, slice, metav1.CreateOptions{})
-	if err != nil {
-		// Try update if create fails
-		existing, getErr := p.client.ResourceSlices().Get(ctx, sliceName, metav1.GetOptions{})
-		if getErr != nil {
-			return fmt.Errorf("failed to create or get ResourceSlice: create=%v, get=%v", err, getErr)
-		}
-		slice.ResourceVersion = existing.ResourceVersion
-		_, err = p.client.ResourceSlices().Update(ctx, slice, metav1.UpdateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to update ResourceSlice: %w", err)
-		}
-	}
-
-	klog.Infof("Published ResourceSlice %s", sliceName)
-	return nil
 }
 
-// UnpublishResources removes the ResourceSlice
-func (p *ResourcePublisher) UnpublishResources(ctx context.Context) error {
-	sliceName := fmt.Sprintf("%s-%s", p.nodeName, p.driverName)
-	err := p.client.ResourceSlices().Delete(ctx, sliceName, metav1.DeleteOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to delete ResourceSlice: %w", err)
-	}
-	klog.Infof("Unpublished ResourceSlice %s", sliceName)
-	return nil
+// Update changes the advertised device pool at runtime. The controller
+// reconciles the new desired state against whatever ResourceSlices already
+// exist, so drivers can grow or shrink their pool without restarting.
+func (p *ResourcePublisher) Update(devices []resourceapi.Device) {
+	p.controller.setDesired(devices)
 }
 
-// StartPublishing starts a goroutine that keeps the ResourceSlice updated
+// StartPublishing runs the ResourceSlice controller until ctx is canceled,
+// then tears down every slice this driver published on this node. Run has
+// already joined its worker goroutine by the time it returns, so the
+// reconcile below is the only one touching the controller at this point.
 func (p *ResourcePublisher) StartPublishing(ctx context.Context) {
-	// Initial publish
-	if err := p.PublishResources(ctx); err != nil {
-		klog.Errorf("Failed to publish resources: %v", err)
-	}
-
-	// Periodic refresh
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	p.controller.Run(ctx)
 
-	for {
-		select {
-		case <-ctx.Done():
-			// Cleanup on shutdown
-			cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := p.UnpublishResources(cleanupCtx); err != nil {
-				klog.Errorf("Failed to unpublish resources: %v", err)
-			}
-			cancel()
-			return
-		case <-ticker.C:
-			if err := p.PublishResources(ctx); err != nil {
-				klog.Errorf("Failed to refresh resources: %v", err)
-			}
-		}
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.controller.setDesired(nil)
+	if err := p.controller.reconcile(cleanupCtx); err != nil {
+		klog.Errorf("Failed to unpublish resources for %s: %v", p.driverName, err)
 	}
 }
 
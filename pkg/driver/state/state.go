@@ -0,0 +1,116 @@
+// Package state persists per-claim driver state to disk so a restarted
+// driver can rebuild its in-memory bookkeeping without re-querying the API
+// server, the same way kubelet's own DRA claiminfo cache survives restarts.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ClaimInfo is everything NodePrepareResources/NodeUnprepareResources need
+// to remember about a claim across a driver restart.
+type ClaimInfo struct {
+	DriverName     string   `json:"driverName"`
+	ClassName      string   `json:"className,omitempty"`
+	ClaimUID       string   `json:"claimUID"`
+	ClaimName      string   `json:"claimName"`
+	Namespace      string   `json:"namespace"`
+	PodUIDs        []string `json:"podUIDs"`
+	CDIDeviceIDs   []string `json:"cdiDeviceIDs"`
+	CDISpecName    string   `json:"cdiSpecName,omitempty"`
+	ResourceHandle string   `json:"resourceHandle,omitempty"`
+}
+
+// checkpoint is the on-disk representation written by Checkpointer.
+type checkpoint struct {
+	Version string               `json:"version"`
+	Claims  map[string]ClaimInfo `json:"claims"`
+}
+
+const checkpointVersion = "v1"
+
+// Checkpointer reads and atomically rewrites a single checkpoint file
+// holding every ClaimInfo this driver currently knows about.
+type Checkpointer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewCheckpointer creates a Checkpointer backed by <stateDir>/<checkpointName>,
+// creating stateDir if necessary.
+func NewCheckpointer(stateDir, checkpointName string) (*Checkpointer, error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %w", stateDir, err)
+	}
+	return &Checkpointer{path: filepath.Join(stateDir, checkpointName)}, nil
+}
+
+// GetOrCreate loads the checkpoint file, returning an empty claim map (and
+// writing a fresh, empty checkpoint) if none exists yet.
+func (c *Checkpointer) GetOrCreate() (map[string]ClaimInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read checkpoint %s: %w", c.path, err)
+		}
+		empty := checkpoint{Version: checkpointVersion, Claims: map[string]ClaimInfo{}}
+		if err := c.writeLocked(empty); err != nil {
+			return nil, err
+		}
+		return map[string]ClaimInfo{}, nil
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", c.path, err)
+	}
+	if cp.Claims == nil {
+		cp.Claims = map[string]ClaimInfo{}
+	}
+	return cp.Claims, nil
+}
+
+// Store atomically rewrites the checkpoint file with claims.
+func (c *Checkpointer) Store(claims map[string]ClaimInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeLocked(checkpoint{Version: checkpointVersion, Claims: claims})
+}
+
+// writeLocked writes cp to a temp file in the same directory and renames it
+// over c.path, so a crash mid-write never leaves a corrupt checkpoint.
+func (c *Checkpointer) writeLocked(cp checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp checkpoint file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp checkpoint file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp checkpoint file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp checkpoint file into place: %w", err)
+	}
+	return nil
+}
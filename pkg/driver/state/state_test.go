@@ -0,0 +1,136 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetOrCreateMissingFileWritesEmptyCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+
+	claims, err := c.GetOrCreate()
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	if len(claims) != 0 {
+		t.Fatalf("got %d claims, want 0", len(claims))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "checkpoint.json")); err != nil {
+		t.Fatalf("expected checkpoint file to be created: %v", err)
+	}
+}
+
+func TestStoreGetOrCreateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+
+	want := map[string]ClaimInfo{
+		"claim-1": {
+			DriverName:     "file.dra.example.com",
+			ClassName:      "file-class",
+			ClaimUID:       "claim-1",
+			ClaimName:      "my-claim",
+			Namespace:      "default",
+			PodUIDs:        []string{"pod-uid-1"},
+			CDIDeviceIDs:   []string{"file.dra.example.com/file=file1"},
+			CDISpecName:    "file.dra.example.com-abc.json",
+			ResourceHandle: `{"content":"hello"}`,
+		},
+	}
+
+	if err := c.Store(want); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	// A second Checkpointer stands in for a restarted driver rereading the
+	// same file from disk.
+	c2, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+	got, err := c2.GetOrCreate()
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip mismatch:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestGetOrCreateCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checkpoint.json")
+	if err := os.WriteFile(path, []byte("{not valid json"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+
+	if _, err := c.GetOrCreate(); err == nil {
+		t.Fatal("expected an error for a corrupt checkpoint file, got nil")
+	}
+}
+
+func TestStoreLeavesNoTempFilesBehind(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+
+	if err := c.Store(map[string]ClaimInfo{"claim-1": {ClaimUID: "claim-1"}}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != "checkpoint.json" {
+			t.Fatalf("unexpected leftover file %s; rename-based write should leave only the final checkpoint", e.Name())
+		}
+	}
+}
+
+// TestGetOrCreateSurvivesLeftoverTempFile simulates a crash between the
+// temp-file write and the rename over c.path: a stale ".tmp-*" file sits
+// next to the real checkpoint, and GetOrCreate must still read the
+// checkpoint itself rather than tripping over the leftover.
+func TestGetOrCreateSurvivesLeftoverTempFile(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCheckpointer(dir, "checkpoint.json")
+	if err != nil {
+		t.Fatalf("NewCheckpointer: %v", err)
+	}
+
+	want := map[string]ClaimInfo{"claim-1": {ClaimUID: "claim-1"}}
+	if err := c.Store(want); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "checkpoint.json.tmp-crashed"), []byte("garbage"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	got, err := c.GetOrCreate()
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}